@@ -2,15 +2,21 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+
+	"github.com/an5uz/maxo/lexer"
 )
 
 var options struct {
-	version   bool
+	version bool
+	dialect string
 }
 
 func main() {
-	flag.BoolVar(&options.version,"version", false, "View the version of maxo lang")
+	flag.BoolVar(&options.version, "version", false, "View the version of maxo lang")
+	flag.StringVar(&options.dialect, "dialect", "maxo", `Dialect to lex the input with: "maxo" or "json"`)
 	flag.Parse()
 	if options.version {
 		print("MAXOv0.0.1")
@@ -21,4 +27,31 @@ func main() {
 		print("type -h or --help for help on usage")
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+
+	d, err := dialectFor(options.dialect)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	l := lexer.Lex(strings.Join(flag.Args(), " "), &d)
+	for {
+		item := l.NextItem()
+		fmt.Printf("%s %q\n", item.Kind, item.Value)
+		if item.Kind == lexer.ItemEOF || item.Kind == lexer.ItemError {
+			break
+		}
+	}
+}
+
+// dialectFor resolves the -dialect flag to the lexer.Dialect it names.
+func dialectFor(name string) (lexer.Dialect, error) {
+	switch name {
+	case "maxo", "":
+		return lexer.DefaultDialect(), nil
+	case "json":
+		return lexer.JSONDialect(), nil
+	default:
+		return lexer.Dialect{}, fmt.Errorf("unknown -dialect %q: want \"maxo\" or \"json\"", name)
+	}
+}
@@ -3,6 +3,8 @@ package lexer
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -12,23 +14,53 @@ import (
 type ItemKind int
 
 const (
-	WhiteSpace ItemKind = iota
-	LineBreak
-	NewLine
-	Text
-	Digit
-	StringQuote
-	SingleLineComment
-	MultiLineCommentStart
-	MultiLineCommentEnd
-	Operator
-	EOF
+	ItemWhiteSpace ItemKind = iota
+	ItemLineBreak
+	ItemNewLine
+	ItemText
+	ItemIdent
+	ItemKeyword
+	ItemDigit
+	ItemStringQuote
+	ItemSingleLineComment
+	ItemMultiLineCommentStart
+	ItemMultiLineCommentEnd
+	ItemOperator
+	ItemEOF
 	ItemError
 )
 
-// item is accumulated while lexing the provided input, and emitted over a
-// channel to the parser. Items could also be called tokens as we tokenize the
-// input.
+// itemKindNames holds the debug name for each ItemKind, in declaration
+// order, so String stays trivial to keep in sync when a kind is added.
+var itemKindNames = [...]string{
+	ItemWhiteSpace:            "WhiteSpace",
+	ItemLineBreak:             "LineBreak",
+	ItemNewLine:               "NewLine",
+	ItemText:                  "Text",
+	ItemIdent:                 "Ident",
+	ItemKeyword:               "Keyword",
+	ItemDigit:                 "Digit",
+	ItemStringQuote:           "StringQuote",
+	ItemSingleLineComment:     "SingleLineComment",
+	ItemMultiLineCommentStart: "MultiLineCommentStart",
+	ItemMultiLineCommentEnd:   "MultiLineCommentEnd",
+	ItemOperator:              "Operator",
+	ItemEOF:                   "EOF",
+	ItemError:                 "Error",
+}
+
+// String renders k using its declared name rather than a bare integer, for
+// readable debug and error output.
+func (k ItemKind) String() string {
+	if int(k) < 0 || int(k) >= len(itemKindNames) {
+		return fmt.Sprintf("ItemKind(%d)", int(k))
+	}
+	return itemKindNames[k]
+}
+
+// item is accumulated while lexing the provided input, and handed to the
+// parser on demand via NextItem. Items could also be called tokens as we
+// tokenize the input.
 type Item struct {
 	Position int
 
@@ -38,107 +70,367 @@ type Item struct {
 
 	// value is the segment of data we've accumulated.
 	Value string
+
+	// Line and Column locate the start of the item in the input, both
+	// 1-indexed, so diagnostics can point at a human-readable position.
+	Line   int
+	Column int
 }
 
 const eof = rune(0)
 
 // stateFn is a function that is specific to a state within the string.
+// A stateFn that wants to keep scanning without producing an item returns
+// the next stateFn directly. A stateFn that has just emitted an item
+// instead stashes the resume state on the lexer itself and returns nil,
+// which pauses the machine until the caller asks for more via NextItem.
 type stateFn func(*Lexer) stateFn
 
-// lex creates a lexer and starts scanning the provided input.
-func Lex(input string) *Lexer {
-	l := &Lexer{
-		input: input,
-		state: lexText,
-		Items: make(chan Item, 1),
+// Dialect configures the vocabulary lexText and friends scan for, so the
+// same state machine can lex more than one language.
+type Dialect struct {
+	// Keywords maps identifier spellings (as matched by IdentStart/
+	// IdentCont) to the ItemKind they should be emitted as instead of
+	// ItemIdent.
+	Keywords map[string]ItemKind
+
+	// Operators lists the recognized operators. They're matched
+	// longest-first, so e.g. "==" doesn't lex as two "=".
+	Operators []string
+
+	// LineComment and BlockCommentStart/BlockCommentEnd are the comment
+	// delimiters. Leave a field "" to disable that comment style.
+	LineComment       string
+	BlockCommentStart string
+	BlockCommentEnd   string
+
+	// StringQuotes lists the runes that open (and, matching, close) a
+	// string literal.
+	StringQuotes []rune
+
+	// IdentStart and IdentCont decide whether a rune can start, or
+	// continue, an identifier. Leave both nil to disable identifiers,
+	// leaving everything that isn't a number, string, comment or operator
+	// to fall through to ItemText.
+	IdentStart func(rune) bool
+	IdentCont  func(rune) bool
+}
+
+// DefaultDialect reproduces the lexer's original, hardcoded behavior: C-style
+// comments, single/double-quoted strings, a small table of punctuation
+// operators, and no keywords or identifiers.
+func DefaultDialect() Dialect {
+	return Dialect{
+		Operators: []string{
+			"==", "!=", "<=", ">=", "&&", "||",
+			"+", "-", "*", "/", "=", "<", ">", "!",
+			"(", ")", "{", "}", "[", "]", ",", ";", ".", ":",
+		},
+		LineComment:       "//",
+		BlockCommentStart: "/*",
+		BlockCommentEnd:   "*/",
+		StringQuotes:      []rune{'"', '\''},
+	}
+}
+
+// JSONDialect lexes JSON's vocabulary: object/array punctuation, strings,
+// numbers, and the true/false/null keywords. It has no comments, matching
+// the JSON spec.
+func JSONDialect() Dialect {
+	return Dialect{
+		Keywords: map[string]ItemKind{
+			"true":  ItemKeyword,
+			"false": ItemKeyword,
+			"null":  ItemKeyword,
+		},
+		Operators:    []string{"{", "}", "[", "]", ":", ","},
+		StringQuotes: []rune{'"'},
+		IdentStart:   unicode.IsLetter,
+		IdentCont:    unicode.IsLetter,
+	}
+}
+
+// buildOperatorIndex sorts ops longest-first for greedy matching and
+// collects the set of runes that can start one of them.
+func buildOperatorIndex(ops []string) (sorted []string, starts map[rune]bool) {
+	sorted = append([]string(nil), ops...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	starts = make(map[rune]bool, len(sorted))
+	for _, op := range sorted {
+		if op == "" {
+			continue
+		}
+		starts[[]rune(op)[0]] = true
+	}
+	return sorted, starts
+}
+
+// Lex creates a lexer that scans input according to d. A nil d uses
+// DefaultDialect.
+func Lex(input string, d *Dialect) *Lexer {
+	if d == nil {
+		def := DefaultDialect()
+		d = &def
 	}
 
-	go l.scan()
+	ops, starts := buildOperatorIndex(d.Operators)
+
+	l := &Lexer{
+		input:          input,
+		state:          lexText,
+		line:           1,
+		col:            1,
+		startLine:      1,
+		startCol:       1,
+		dialect:        d,
+		operators:      ops,
+		operatorStarts: starts,
+	}
 
 	return l
 }
 
 // lexer is created to manage an individual scanning/parsing operation.
 type Lexer struct {
-	input    string    // we'll store the string being parsed
-	start    int       // the position we started scanning
-	Position int       // the current position of our scan
-	width    int       // we'll be using runes which can be double byte
-	state    stateFn   // the current state function
-	Items    chan Item // the channel we'll use to communicate between the lexer and the parser
+	input    string  // we'll store the string being parsed
+	start    int     // the position we started scanning
+	Position int     // the current position of our scan
+	width    int     // we'll be using runes which can be double byte
+	state    stateFn // the state to resume from on the next NextItem call
+	item     Item    // the most recently stashed item, set by emit
+	emitted  bool    // true once emit/errorf has stashed an item this advance() call
+	atEOF    bool    // true once the terminal ItemEOF item has been handed out
+
+	// line/col track the 1-indexed position of the rune at Position (i.e.
+	// the next rune next() will return). prevLine/prevCol hold the value
+	// from before the last next() call, so a single backup() can restore
+	// it. startLine/startCol snapshot line/col at the last ignore(), i.e.
+	// the coordinates of the token currently being accumulated.
+	line, col         int
+	prevLine, prevCol int
+	startLine         int
+	startCol          int
+
+	// commentDepth tracks how many unclosed "/*" we've seen, so nested
+	// block comments close on their matching "*/" rather than the first
+	// one encountered.
+	commentDepth int
+
+	// peeked holds items the state machine has already produced but that
+	// NextItem hasn't handed out yet: Peek/PeekN push onto the back,
+	// Backup pushes onto the front, and NextItem always drains the front
+	// before resuming the state machine.
+	peeked []Item
+
+	// dialect is the vocabulary being scanned. operators/operatorStarts
+	// are derived from dialect.Operators once, at construction, so
+	// lexOperator doesn't re-sort on every match attempt.
+	dialect        *Dialect
+	operators      []string
+	operatorStarts map[rune]bool
 }
 
-// emit sends a item over the channel so the parser can collect and manage
-// each segment.
+// emit stashes an item on the lexer so the calling state function can hand
+// control back to NextItem.
 func (l *Lexer) emit(k ItemKind) {
 	accumulation := l.input[l.start:l.Position]
 
-	i := Item{
+	l.item = Item{
 		Position: l.start,
 		Kind:     k,
 		Value:    accumulation,
+		Line:     l.startLine,
+		Column:   l.startCol,
 	}
 
-	l.Items <- i
+	l.emitted = true
+	l.ignore() // reset our scanner now that we've stashed a segment
+}
+
+// NextItem returns the next item, either one already sitting in the peek
+// buffer (from Peek, PeekN or Backup) or, once that's drained, the next one
+// produced by resuming the state machine.
+func (l *Lexer) NextItem() Item {
+	if len(l.peeked) > 0 {
+		item := l.peeked[0]
+		l.peeked = l.peeked[1:]
+		return item
+	}
+	return l.advance()
+}
+
+// Peek returns the next item without consuming it: the following NextItem
+// (or Peek) call will return the same item. A single Peek is O(1) once the
+// item is buffered, since it's just a slice read.
+func (l *Lexer) Peek() Item {
+	if len(l.peeked) == 0 {
+		l.peeked = append(l.peeked, l.advance())
+	}
+	return l.peeked[0]
+}
+
+// PeekN returns the next n items without consuming them. It grows the peek
+// buffer to n items, producing whichever of them haven't been scanned yet,
+// so repeated PeekN calls for the same or a smaller n are free.
+func (l *Lexer) PeekN(n int) []Item {
+	for len(l.peeked) < n {
+		l.peeked = append(l.peeked, l.advance())
+	}
+	items := make([]Item, n)
+	copy(items, l.peeked[:n])
+	return items
+}
 
-	l.ignore() // reset our scanner now that we've dispatched a segment
+// Backup pushes a single item back onto the front of the lexer, so the next
+// NextItem or Peek call sees it again. It's the parser's escape hatch for
+// committing to an alternative production after Peek/PeekN has already
+// looked past the item in question.
+func (l *Lexer) Backup(item Item) {
+	l.peeked = append([]Item{item}, l.peeked...)
 }
 
-// nextItem pulls an item from the lexer's result channel.
-func (l *Lexer) nextItem() Item {
-	return <-l.Items
+// advance resumes the state machine from wherever it last paused, running
+// state functions until one of them stashes an item, and returns it. This
+// replaces the old channel/goroutine handoff with an on-demand pull: the
+// caller asks for exactly as many items as it needs instead of ranging over
+// a channel fed by a background goroutine.
+func (l *Lexer) advance() Item {
+	l.emitted = false
+	for {
+		if l.state == nil {
+			if l.emitted {
+				return l.item
+			}
+			if !l.atEOF {
+				l.atEOF = true
+				l.item = Item{Kind: ItemEOF}
+			}
+			return l.item
+		}
+
+		next := l.state(l)
+		if next == nil {
+			if l.emitted {
+				return l.item
+			}
+			continue
+		}
+		l.state = next
+	}
 }
 
 // ignore resets the start position to the current scan position effectively
 // ignoring any input.
 func (l *Lexer) ignore() {
 	l.start = l.Position
+	l.startLine, l.startCol = l.line, l.col
 }
 
-// next advances the lexer state to the next rune.
+// next advances the lexer state to the next rune, tracking the line/column
+// it sits on so errors and items can report a human-readable position.
 func (l *Lexer) next() (r rune) {
 	if l.Position >= len(l.input) {
 		l.width = 0
+		l.prevLine, l.prevCol = l.line, l.col
 		return eof
 	}
 
 	r, l.width = utf8.DecodeRuneInString(l.input[l.Position:])
 	l.Position += l.width
+
+	l.prevLine, l.prevCol = l.line, l.col
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+
 	return r
 }
 
-// backup allows us to step back one run1e which is helpful when you've crossed
-// a boundary from one state to another.
+// backup allows us to step back one rune which is helpful when you've
+// crossed a boundary from one state to another. Only a single level of
+// backup is supported, mirroring the single l.width this restores.
 func (l *Lexer) backup() {
-	l.Position = l.Position - 1
+	l.Position -= l.width
+	l.line, l.col = l.prevLine, l.prevCol
+}
+
+// peekRune returns the rune at the current position without consuming it,
+// used by states that need to decide what comes next (e.g. "/" starting a
+// comment vs. an operator) without disturbing next()/backup()'s one-level
+// history.
+func (l *Lexer) peekRune() rune {
+	if l.Position >= len(l.input) {
+		return eof
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.Position:])
+	return r
 }
 
-// scan will step through the provided text and execute state functions as
-// state changes are observed in the provided input.
-func (l *Lexer) scan() {
-	// When we begin processing, let's assume we're going to process text.
-	// One state function will return another until `nil` is returned to signal
-	// the end of our process.
-	for fn := lexText; fn != nil; {
-		fn = fn(l)
+// hasPrefix reports whether the input starting at the current position
+// begins with prefix. An empty prefix never matches, so a dialect can
+// disable a delimiter (e.g. no line comments) by leaving it "".
+func (l *Lexer) hasPrefix(prefix string) bool {
+	return prefix != "" && strings.HasPrefix(l.input[l.Position:], prefix)
+}
+
+// isQuoteRune reports whether r opens a string literal in l's dialect.
+func (l *Lexer) isQuoteRune(r rune) bool {
+	for _, q := range l.dialect.StringQuotes {
+		if r == q {
+			return true
+		}
 	}
+	return false
+}
 
-	close(l.Items)
+// isOperatorStart reports whether r can begin one of l's dialect's
+// operators.
+func (l *Lexer) isOperatorStart(r rune) bool {
+	return l.operatorStarts[r]
 }
 
-func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
-	msg := fmt.Sprintf(format, args...)
-	l.Items <- Item{
-		Kind:  ItemError,
-		Value: msg,
+// startsNegativeNumber reports whether the '-' at the current position is
+// immediately followed by a digit, i.e. it's a number's sign rather than,
+// say, an operator. Only relevant for dialects (like JSONDialect) that
+// don't already claim '-' as an operator start.
+func (l *Lexer) startsNegativeNumber() bool {
+	l.next() // step past '-' to look at what follows
+	digit := unicode.IsDigit(l.peekRune())
+	l.backup()
+	return digit
+}
+
+// acceptRun consumes a run of runes found in valid, leaving the position on
+// the first rune that isn't.
+func (l *Lexer) acceptRun(valid string) {
+	for strings.ContainsRune(valid, l.next()) {
 	}
+	l.backup()
+}
 
+// errorf stashes an error item, located at the start of the token being
+// scanned, and halts the machine.
+func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
+	l.item = Item{
+		Kind:   ItemError,
+		Value:  fmt.Sprintf(format, args...),
+		Line:   l.startLine,
+		Column: l.startCol,
+	}
+	l.emitted = true
+	l.state = nil
 	return nil
 }
 
-// lexEOF emits the accumulated data classified by the provided itemKind and
-// signals that we've reached the end of our lexing by returning `nil` instead
-// of a state function.
+// lexEOF emits whatever has been accumulated, classified by the provided
+// itemKind, and halts the machine. If nothing was accumulated (we're already
+// sitting at the boundary, with nothing left to classify) it emits nothing,
+// letting advance() fall straight through to synthesizing the terminal
+// ItemEOF instead of handing out a spurious empty item first.
 func (l *Lexer) lexEOF(k ItemKind) stateFn {
 
 	//	l.backup()
@@ -146,48 +438,229 @@ func (l *Lexer) lexEOF(k ItemKind) stateFn {
 		l.ignore()
 	}
 
-	l.emit(k)
-	l.emit(EOF)
+	if l.Position > l.start {
+		l.emit(k)
+	}
+	l.state = nil
 	return nil
 }
 
-// lexText scans what is expected to be text.
+// lexText scans what is expected to be text, dispatching to a more specific
+// state as soon as it recognizes, per the active dialect, the start of an
+// identifier, number, string, comment or operator.
 func lexText(l *Lexer) stateFn {
 	for {
-		r := l.next()
+		if l.Position >= len(l.input) {
+			return l.lexEOF(ItemText)
+		}
+
+		r := l.peekRune()
 		switch {
-		case r == eof:
-			return l.lexEOF(Text)
 		case unicode.IsSpace(r):
-			l.backup()
-
-			// emit any text we've accumulated.
-			if l.Position > l.start {
-				l.emit(Text)
-			}
-			return lexWhitespace
+			return l.dispatchText(lexWhitespace)
+		case l.dialect.IdentStart != nil && l.dialect.IdentStart(r):
+			return l.dispatchText(lexIdent)
+		case unicode.IsDigit(r):
+			return l.dispatchText(lexNumber)
+		case l.isQuoteRune(r):
+			return l.dispatchText(lexString)
+		case l.hasPrefix(l.dialect.LineComment):
+			return l.dispatchText(lexLineComment)
+		case l.hasPrefix(l.dialect.BlockCommentStart):
+			return l.dispatchText(lexBlockComment)
+		case l.isOperatorStart(r):
+			return l.dispatchText(lexOperator)
+		case r == '-' && l.startsNegativeNumber():
+			return l.dispatchText(lexNumber)
+		default:
+			l.next()
 		}
 	}
 }
 
+// dispatchText emits whatever plain text has accumulated before handing off
+// to next, so the boundary rune next is about to re-scan stays out of the
+// ItemText item.
+func (l *Lexer) dispatchText(next stateFn) stateFn {
+	if l.Position > l.start {
+		l.emit(ItemText)
+		l.state = next
+		return nil
+	}
+	return next
+}
+
 // lexWhitespace scans what is expected to be whitespace.
 func lexWhitespace(l *Lexer) stateFn {
 	for {
 		r := l.next()
 		switch {
 		case r == eof:
-			return l.lexEOF(WhiteSpace)
+			return l.lexEOF(ItemWhiteSpace)
 		case !unicode.IsSpace(r):
 			l.backup()
 			if l.Position > l.start {
-				l.emit(WhiteSpace)
+				l.emit(ItemWhiteSpace)
+				l.state = lexText
+				return nil
 			}
 			return lexText
 		}
 	}
 }
-// TODO add lexItemKind stuff that is still missing
 
+const digits = "0123456789"
+
+// lexNumber scans an integer, with an optional leading sign, fractional
+// part and exponent, in the same shape as a JSON number.
+func lexNumber(l *Lexer) stateFn {
+	if l.peekRune() == '-' {
+		l.next()
+	}
+	l.acceptRun(digits)
+	if l.peekRune() == '.' {
+		l.next()
+		l.acceptRun(digits)
+	}
+	if r := l.peekRune(); r == 'e' || r == 'E' {
+		l.next()
+		if s := l.peekRune(); s == '+' || s == '-' {
+			l.next()
+		}
+		l.acceptRun(digits)
+	}
+
+	l.emit(ItemDigit)
+	l.state = lexText
+	return nil
+}
+
+// lexIdent scans an identifier, per the dialect's IdentStart/IdentCont, and
+// emits it as the ItemKind its Keywords table names it, or ItemIdent
+// otherwise.
+func lexIdent(l *Lexer) stateFn {
+	l.next() // IdentStart already matched this rune in lexText
+	for l.dialect.IdentCont != nil && l.dialect.IdentCont(l.peekRune()) {
+		l.next()
+	}
+
+	word := l.input[l.start:l.Position]
+	kind, ok := l.dialect.Keywords[word]
+	if !ok {
+		kind = ItemIdent
+	}
+
+	l.emit(kind)
+	l.state = lexText
+	return nil
+}
+
+// lexString scans a single- or double-quoted string literal, honoring
+// backslash escapes, up to its closing quote.
+func lexString(l *Lexer) stateFn {
+	quote := l.next()
+	for {
+		r := l.next()
+		switch {
+		case r == eof:
+			return l.errorf("unterminated string literal")
+		case r == '\\':
+			if l.next() == eof {
+				return l.errorf("unterminated string literal")
+			}
+		case r == quote:
+			l.emit(ItemStringQuote)
+			l.state = lexText
+			return nil
+		}
+	}
+}
+
+// lexLineComment scans a dialect.LineComment comment up to, but not
+// including, the terminating newline.
+func lexLineComment(l *Lexer) stateFn {
+	for range l.dialect.LineComment {
+		l.next()
+	}
+	for {
+		r := l.next()
+		switch r {
+		case eof:
+			return l.lexEOF(ItemSingleLineComment)
+		case '\n':
+			l.backup()
+			l.emit(ItemSingleLineComment)
+			l.state = lexWhitespace
+			return nil
+		}
+	}
+}
+
+// lexBlockComment scans a dialect.BlockCommentStart/BlockCommentEnd comment,
+// tracking nesting depth on the lexer so an inner start/end pair doesn't
+// close the outer comment early. It emits the opening delimiter as
+// ItemMultiLineCommentStart and the remaining body, including the matching
+// closing delimiter, as ItemMultiLineCommentEnd.
+func lexBlockComment(l *Lexer) stateFn {
+	for range l.dialect.BlockCommentStart {
+		l.next()
+	}
+	l.emit(ItemMultiLineCommentStart)
+
+	l.commentDepth = 1
+	l.state = lexBlockCommentBody
+	return nil
+}
+
+// lexBlockCommentBody scans everything after the opening delimiter up to,
+// and including, the matching closing delimiter.
+func lexBlockCommentBody(l *Lexer) stateFn {
+	start, end := l.dialect.BlockCommentStart, l.dialect.BlockCommentEnd
+	for {
+		switch {
+		case l.Position >= len(l.input):
+			l.commentDepth = 0
+			return l.errorf("unterminated block comment")
+		case l.hasPrefix(start):
+			for range start {
+				l.next()
+			}
+			l.commentDepth++
+		case l.hasPrefix(end):
+			for range end {
+				l.next()
+			}
+			l.commentDepth--
+			if l.commentDepth == 0 {
+				l.emit(ItemMultiLineCommentEnd)
+				l.state = lexText
+				return nil
+			}
+		default:
+			l.next()
+		}
+	}
+}
+
+// lexOperator matches the longest operator in the dialect starting at the
+// current position. If none match, the rune is left for lexText to sweep up
+// as ordinary text.
+func lexOperator(l *Lexer) stateFn {
+	rest := l.input[l.Position:]
+	for _, op := range l.operators {
+		if strings.HasPrefix(rest, op) {
+			for range op {
+				l.next()
+			}
+			l.emit(ItemOperator)
+			l.state = lexText
+			return nil
+		}
+	}
+
+	l.next()
+	return lexText
+}
 
 // ParseSimple is a simple routine to preserve whitespace while reversing the
 // characters in words.
@@ -219,4 +692,4 @@ func Reverse(s string) string {
 		r[i], r[j] = r[j], r[i]
 	}
 	return string(r)
-}
\ No newline at end of file
+}
@@ -0,0 +1,486 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// drain pulls items from l until ItemEOF or ItemError, returning how many
+// were produced (counting that terminal item) and the terminal item itself.
+func drain(l *Lexer) (int, Item) {
+	count := 0
+	for {
+		item := l.NextItem()
+		count++
+		if item.Kind == ItemEOF || item.Kind == ItemError {
+			return count, item
+		}
+	}
+}
+
+func TestNextItemReachesEOF(t *testing.T) {
+	l := Lex("hello world", nil)
+	count, last := drain(l)
+	if last.Kind != ItemEOF {
+		t.Fatalf("got terminal Kind %v, want ItemEOF", last.Kind)
+	}
+	if count < 2 {
+		t.Fatalf("expected at least one item before ItemEOF")
+	}
+}
+
+func TestNextItemPastEOFKeepsReturningEOF(t *testing.T) {
+	l := Lex("hi", nil)
+	drain(l)
+
+	for i := 0; i < 3; i++ {
+		if item := l.NextItem(); item.Kind != ItemEOF {
+			t.Fatalf("call %d after ItemEOF: got Kind %v, want ItemEOF", i, item.Kind)
+		}
+	}
+}
+
+func TestItemPositionsAcrossMultiByteRunes(t *testing.T) {
+	l := Lex("héllo wörld", nil)
+
+	text1 := l.NextItem()
+	if text1.Value != "héllo" || text1.Line != 1 || text1.Column != 1 {
+		t.Fatalf("got %+v, want Value=héllo Line=1 Column=1", text1)
+	}
+
+	ws := l.NextItem()
+	if ws.Value != " " || ws.Line != 1 || ws.Column != 6 {
+		t.Fatalf("got %+v, want Value=\" \" Line=1 Column=6", ws)
+	}
+
+	text2 := l.NextItem()
+	if text2.Value != "wörld" || text2.Line != 1 || text2.Column != 7 {
+		t.Fatalf("got %+v, want Value=wörld Line=1 Column=7", text2)
+	}
+}
+
+func TestItemPositionsAcrossCRLF(t *testing.T) {
+	l := Lex("a\r\nb", nil)
+
+	first := l.NextItem()
+	if first.Value != "a" || first.Line != 1 || first.Column != 1 {
+		t.Fatalf("got %+v, want Value=a Line=1 Column=1", first)
+	}
+
+	ws := l.NextItem()
+	if ws.Value != "\r\n" || ws.Line != 1 || ws.Column != 2 {
+		t.Fatalf("got %+v, want Value=\\r\\n Line=1 Column=2", ws)
+	}
+
+	second := l.NextItem()
+	if second.Value != "b" || second.Line != 2 || second.Column != 1 {
+		t.Fatalf("got %+v, want Value=b Line=2 Column=1", second)
+	}
+}
+
+func TestBackupRestoresLineAndColumnAcrossNewline(t *testing.T) {
+	l := Lex("a\nbc", nil)
+
+	if r := l.next(); r != 'a' {
+		t.Fatalf("got rune %q, want 'a'", r)
+	}
+	if r := l.next(); r != '\n' {
+		t.Fatalf("got rune %q, want '\\n'", r)
+	}
+	if r := l.next(); r != 'b' {
+		t.Fatalf("got rune %q, want 'b'", r)
+	}
+	if l.line != 2 || l.col != 2 {
+		t.Fatalf("after reading 'b': got line=%d col=%d, want line=2 col=2", l.line, l.col)
+	}
+
+	l.backup()
+	if l.line != 2 || l.col != 1 {
+		t.Fatalf("after backup: got line=%d col=%d, want line=2 col=1", l.line, l.col)
+	}
+	if r := l.next(); r != 'b' {
+		t.Fatalf("re-reading after backup: got rune %q, want 'b'", r)
+	}
+}
+
+func TestLexNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"42", "42"},
+		{"3.14", "3.14"},
+		{"2e10", "2e10"},
+		{"1.5e-3", "1.5e-3"},
+		{"6E+2", "6E+2"},
+	}
+
+	for _, tt := range tests {
+		l := Lex(tt.input, nil)
+		item := l.NextItem()
+		if item.Kind != ItemDigit || item.Value != tt.want {
+			t.Errorf("Lex(%q): got Kind=%v Value=%q, want ItemDigit %q", tt.input, item.Kind, item.Value, tt.want)
+		}
+	}
+}
+
+func TestLexStrings(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"hello"`, `"hello"`},
+		{`'hello'`, `'hello'`},
+		{`"with \"escape\""`, `"with \"escape\""`},
+	}
+
+	for _, tt := range tests {
+		l := Lex(tt.input, nil)
+		item := l.NextItem()
+		if item.Kind != ItemStringQuote || item.Value != tt.want {
+			t.Errorf("Lex(%q): got Kind=%v Value=%q, want ItemStringQuote %q", tt.input, item.Kind, item.Value, tt.want)
+		}
+	}
+}
+
+func TestLexUnterminatedStringIsAnError(t *testing.T) {
+	l := Lex(`"never closes`, nil)
+	item := l.NextItem()
+	if item.Kind != ItemError {
+		t.Fatalf("got Kind=%v, want ItemError", item.Kind)
+	}
+}
+
+func TestLexLineComment(t *testing.T) {
+	l := Lex("// a comment\nx", nil)
+
+	comment := l.NextItem()
+	if comment.Kind != ItemSingleLineComment || comment.Value != "// a comment" {
+		t.Fatalf("got %+v, want ItemSingleLineComment %q", comment, "// a comment")
+	}
+
+	ws := l.NextItem()
+	if ws.Kind != ItemWhiteSpace || ws.Value != "\n" {
+		t.Fatalf("got %+v, want ItemWhiteSpace %q", ws, "\n")
+	}
+}
+
+func TestLexBlockComment(t *testing.T) {
+	l := Lex("/* body */", nil)
+
+	start := l.NextItem()
+	if start.Kind != ItemMultiLineCommentStart || start.Value != "/*" {
+		t.Fatalf("got %+v, want ItemMultiLineCommentStart %q", start, "/*")
+	}
+
+	end := l.NextItem()
+	if end.Kind != ItemMultiLineCommentEnd || end.Value != " body */" {
+		t.Fatalf("got %+v, want ItemMultiLineCommentEnd %q", end, " body */")
+	}
+}
+
+func TestLexNestedBlockComment(t *testing.T) {
+	l := Lex("/* outer /* inner */ still outer */", nil)
+
+	start := l.NextItem()
+	if start.Kind != ItemMultiLineCommentStart {
+		t.Fatalf("got %+v, want ItemMultiLineCommentStart", start)
+	}
+
+	end := l.NextItem()
+	want := " outer /* inner */ still outer */"
+	if end.Kind != ItemMultiLineCommentEnd || end.Value != want {
+		t.Fatalf("got %+v, want ItemMultiLineCommentEnd %q", end, want)
+	}
+}
+
+func TestLexUnterminatedBlockCommentIsAnError(t *testing.T) {
+	l := Lex("/* never closes", nil)
+	l.NextItem() // ItemMultiLineCommentStart
+	item := l.NextItem()
+	if item.Kind != ItemError {
+		t.Fatalf("got Kind=%v, want ItemError", item.Kind)
+	}
+}
+
+func TestLexOperators(t *testing.T) {
+	for _, op := range DefaultDialect().Operators {
+		l := Lex(op, nil)
+		item := l.NextItem()
+		if item.Kind != ItemOperator || item.Value != op {
+			t.Errorf("Lex(%q): got Kind=%v Value=%q, want ItemOperator %q", op, item.Kind, item.Value, op)
+		}
+	}
+}
+
+func TestLexOperatorMatchesLongestFirst(t *testing.T) {
+	l := Lex("==x", nil)
+	item := l.NextItem()
+	if item.Kind != ItemOperator || item.Value != "==" {
+		t.Fatalf("got %+v, want ItemOperator %q", item, "==")
+	}
+}
+
+func TestItemKindString(t *testing.T) {
+	tests := []struct {
+		kind ItemKind
+		want string
+	}{
+		{ItemWhiteSpace, "WhiteSpace"},
+		{ItemText, "Text"},
+		{ItemDigit, "Digit"},
+		{ItemStringQuote, "StringQuote"},
+		{ItemSingleLineComment, "SingleLineComment"},
+		{ItemMultiLineCommentStart, "MultiLineCommentStart"},
+		{ItemMultiLineCommentEnd, "MultiLineCommentEnd"},
+		{ItemOperator, "Operator"},
+		{ItemEOF, "EOF"},
+		{ItemError, "Error"},
+		{ItemKind(999), "ItemKind(999)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.kind.String(); got != tt.want {
+			t.Errorf("ItemKind(%d).String(): got %q, want %q", int(tt.kind), got, tt.want)
+		}
+	}
+}
+
+// TestGoldenTokenSequence locks in the Item* names the parser package
+// depends on by name; a rename here should fail this test, not silently
+// break parser.parse's switch statement.
+func TestGoldenTokenSequence(t *testing.T) {
+	l := Lex(`x = 1 // note`, nil)
+
+	type token struct {
+		kind  string
+		value string
+	}
+	var got []token
+	for {
+		item := l.NextItem()
+		got = append(got, token{item.Kind.String(), item.Value})
+		if item.Kind == ItemEOF || item.Kind == ItemError {
+			break
+		}
+	}
+
+	want := []token{
+		{"Text", "x"},
+		{"WhiteSpace", " "},
+		{"Operator", "="},
+		{"WhiteSpace", " "},
+		{"Digit", "1"},
+		{"WhiteSpace", " "},
+		{"SingleLineComment", "// note"},
+		{"EOF", ""},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPeekDoesNotConsume(t *testing.T) {
+	l := Lex("x = 1", nil)
+
+	peeked := l.Peek()
+	if peeked.Value != "x" {
+		t.Fatalf("Peek: got %+v, want Value=x", peeked)
+	}
+
+	again := l.Peek()
+	if again != peeked {
+		t.Fatalf("second Peek: got %+v, want %+v", again, peeked)
+	}
+
+	next := l.NextItem()
+	if next != peeked {
+		t.Fatalf("NextItem after Peek: got %+v, want %+v", next, peeked)
+	}
+}
+
+func TestPeekN(t *testing.T) {
+	l := Lex("x = 1", nil)
+
+	items := l.PeekN(3)
+	want := []string{"x", " ", "="}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i, w := range want {
+		if items[i].Value != w {
+			t.Errorf("item %d: got Value=%q, want %q", i, items[i].Value, w)
+		}
+	}
+
+	// The buffered items should still come out one at a time via NextItem,
+	// in the same order, and growing the window further should only
+	// produce what's missing.
+	if got := l.NextItem(); got.Value != "x" {
+		t.Fatalf("got %+v, want Value=x", got)
+	}
+
+	more := l.PeekN(3)
+	wantMore := []string{" ", "=", " "}
+	for i, w := range wantMore {
+		if more[i].Value != w {
+			t.Errorf("item %d: got Value=%q, want %q", i, more[i].Value, w)
+		}
+	}
+}
+
+func TestBackupReplaysItem(t *testing.T) {
+	l := Lex("x = 1", nil)
+
+	first := l.NextItem()
+	second := l.NextItem()
+
+	l.Backup(second)
+	replayed := l.NextItem()
+	if replayed != second {
+		t.Fatalf("replayed item: got %+v, want %+v", replayed, second)
+	}
+
+	// Peeking past the backed-up item should still work afterwards.
+	third := l.Peek()
+	if third.Value != "=" {
+		t.Fatalf("got %+v, want Value==", third)
+	}
+
+	_ = first
+}
+
+func TestInterleavedPeekNextItemBackup(t *testing.T) {
+	l := Lex("x = 1", nil)
+
+	a := l.NextItem() // "x"
+	b := l.Peek()     // " ", not consumed
+	if b.Value != " " {
+		t.Fatalf("Peek: got %+v, want Value=\" \"", b)
+	}
+
+	l.Backup(a) // pretend we need to re-see "x" first
+	replayed := l.NextItem()
+	if replayed != a {
+		t.Fatalf("got %+v, want %+v", replayed, a)
+	}
+
+	if got := l.NextItem(); got != b {
+		t.Fatalf("got %+v, want %+v", got, b)
+	}
+}
+
+func TestJSONDialect(t *testing.T) {
+	d := JSONDialect()
+	l := Lex(`{"a": [1, true, -5, -3.2]}`, &d)
+
+	type token struct {
+		kind  string
+		value string
+	}
+	var got []token
+	for {
+		item := l.NextItem()
+		got = append(got, token{item.Kind.String(), item.Value})
+		if item.Kind == ItemEOF || item.Kind == ItemError {
+			break
+		}
+	}
+
+	want := []token{
+		{"Operator", "{"},
+		{"StringQuote", `"a"`},
+		{"Operator", ":"},
+		{"WhiteSpace", " "},
+		{"Operator", "["},
+		{"Digit", "1"},
+		{"Operator", ","},
+		{"WhiteSpace", " "},
+		{"Keyword", "true"},
+		{"Operator", ","},
+		{"WhiteSpace", " "},
+		{"Digit", "-5"},
+		{"Operator", ","},
+		{"WhiteSpace", " "},
+		{"Digit", "-3.2"},
+		{"Operator", "]"},
+		{"Operator", "}"},
+		{"EOF", ""},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCustomDialectKeywordsAndComments(t *testing.T) {
+	d := Dialect{
+		Keywords:     map[string]ItemKind{"let": ItemKeyword},
+		Operators:    []string{"="},
+		LineComment:  "#",
+		StringQuotes: []rune{'"'},
+		IdentStart:   func(r rune) bool { return r == '_' || unicode.IsLetter(r) },
+		IdentCont:    func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) },
+	}
+	l := Lex("let x1 = 1 # comment", &d)
+
+	kw := l.NextItem()
+	if kw.Kind != ItemKeyword || kw.Value != "let" {
+		t.Fatalf("got %+v, want Keyword \"let\"", kw)
+	}
+
+	l.NextItem() // whitespace
+
+	ident := l.NextItem()
+	if ident.Kind != ItemIdent || ident.Value != "x1" {
+		t.Fatalf("got %+v, want Ident \"x1\"", ident)
+	}
+
+	l.NextItem() // whitespace
+
+	op := l.NextItem()
+	if op.Kind != ItemOperator || op.Value != "=" {
+		t.Fatalf("got %+v, want Operator \"=\"", op)
+	}
+
+	l.NextItem() // whitespace
+	l.NextItem() // digit
+	l.NextItem() // whitespace
+
+	comment := l.NextItem()
+	if comment.Kind != ItemSingleLineComment || comment.Value != "# comment" {
+		t.Fatalf("got %+v, want SingleLineComment \"# comment\"", comment)
+	}
+}
+
+// BenchmarkNextItem measures the on-demand, synchronous scan path. The
+// channel/goroutine design it replaced paid for a goroutine spin-up and a
+// channel send/receive per item; Go's own text/template lexer saw roughly
+// 44% fewer allocations and comparable time savings after the equivalent
+// rewrite.
+func BenchmarkNextItem(b *testing.B) {
+	input := strings.Repeat("hello world ", 64)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := Lex(input, nil)
+		for {
+			item := l.NextItem()
+			if item.Kind == ItemEOF || item.Kind == ItemError {
+				break
+			}
+		}
+	}
+}
@@ -9,13 +9,13 @@ import (
 
 func Parse(text string) (string, error) {
 	p := parser{
-		lex: lexer.Lex(text),
+		lex: lexer.Lex(text, nil),
 	}
 
 	p.parse()
 
 	if p.errItem != nil {
-		return "", fmt.Errorf("error processing the following %q", p.errItem.Value)
+		return "", fmt.Errorf("error at %d:%d: %s", p.errItem.Line, p.errItem.Column, p.errItem.Value)
 	}
 
 	return p.result, nil
@@ -30,7 +30,8 @@ type parser struct {
 func (p *parser) parse() {
 	sb := strings.Builder{}
 
-	for item := range p.lex.Items {
+	for {
+		item := p.lex.NextItem()
 		switch item.Kind {
 		case lexer.ItemEOF:
 			p.result = sb.String()
@@ -43,6 +44,12 @@ func (p *parser) parse() {
 
 		case lexer.ItemWhiteSpace:
 			sb.WriteString(item.Value)
+
+		default:
+			// Everything else (digits, strings, comments, operators,
+			// identifiers, keywords, ...) isn't "text" to reverse; pass it
+			// through verbatim so it isn't silently dropped from the output.
+			sb.WriteString(item.Value)
 		}
 	}
 }
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/an5uz/maxo/lexer"
+)
+
+// TestParseCompiles exercises Parse end to end, which only became possible
+// once lexer.ItemEOF/ItemError/ItemText/ItemWhiteSpace existed to match what
+// this package already referenced.
+func TestParseCompiles(t *testing.T) {
+	got, err := Parse("olleh dlrow")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if want := "hello world"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+// TestParseKeepsNonTextTokens locks in that tokens other than ItemText (a
+// digit, a trailing comment) are passed through verbatim rather
+// than silently dropped: only ItemText gets reversed.
+func TestParseKeepsNonTextTokens(t *testing.T) {
+	got, err := Parse("ih 321 // hello")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if want := "hi 321 // hello"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+// TestParseKeepsIdentAndKeywordTokens locks in that ItemIdent and ItemKeyword
+// (only produced by a dialect with IdentStart/IdentCont set, which
+// lexer.Lex's default dialect leaves nil) are passed through rather than
+// dropped. Parse always lexes with the default dialect, so this drives the
+// parser directly with a custom one, the same way parser.parse itself is
+// dialect-agnostic.
+func TestParseKeepsIdentAndKeywordTokens(t *testing.T) {
+	d := lexer.Dialect{
+		Keywords:   map[string]lexer.ItemKind{"let": lexer.ItemKeyword},
+		IdentStart: func(r rune) bool { return r == '_' || unicode.IsLetter(r) },
+		IdentCont:  func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) },
+	}
+	p := parser{lex: lexer.Lex("let x1", &d)}
+	p.parse()
+
+	if p.errItem != nil {
+		t.Fatalf("parse errored: %+v", p.errItem)
+	}
+	if want := "let x1"; p.result != want {
+		t.Fatalf("got %q, want %q", p.result, want)
+	}
+}
+
+func TestParseReportsPosition(t *testing.T) {
+	_, err := Parse(`"never closes`)
+	if err == nil {
+		t.Fatalf("expected an error for an unterminated string")
+	}
+	if want := "error at 1:1:"; len(err.Error()) < len(want) || err.Error()[:len(want)] != want {
+		t.Fatalf("got %q, want it to start with %q", err.Error(), want)
+	}
+}